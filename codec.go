@@ -0,0 +1,151 @@
+package hemera
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Codec encodes and decodes packets exchanged over NATS. Implementing this
+// interface lets a deployment swap the wire format without touching Add or
+// Act, and lets mixed-codec deployments interoperate as long as every
+// participant ships the codecs it needs to understand.
+type Codec interface {
+	// Name identifies the codec on the wire, e.g. "json", "msgpack" or
+	// "protobuf".
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Binary codecs are prefixed with a one-byte wire tag so a subscriber can
+// pick the matching codec before attempting to decode anything. go-nats
+// does not expose message headers, so the tag travels as the first byte of
+// the payload instead. JSON is never tagged: it is left exactly as
+// encoding/json produces it, so the wire format stays byte-compatible with
+// plain JSON Hemera peers (Go or otherwise) that know nothing about this
+// scheme. A JSON packet always starts with '{' or whitespace, neither of
+// which collides with these tags.
+const (
+	codecTagMsgpack  byte = 0x01
+	codecTagProtobuf byte = 0x02
+)
+
+var codecTags = map[string]byte{
+	"msgpack":  codecTagMsgpack,
+	"protobuf": codecTagProtobuf,
+}
+
+var codecsByTag = map[byte]Codec{
+	codecTagMsgpack:  MsgpackCodec{},
+	codecTagProtobuf: ProtobufCodec{},
+}
+
+// JSONCodec is the default Codec, backed by encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// MsgpackCodec encodes packets as MessagePack, reusing the existing `json`
+// struct tags so it stays in sync with JSONCodec without duplicating them.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// ProtobufCodec encodes packets as a protobuf google.protobuf.Struct. Hemera
+// packets are schema-less by design (Pattern, Meta and Result are all
+// user-defined maps), so rather than requiring every payload to be a
+// generated proto.Message, the codec round-trips through structpb, which is
+// the standard way to carry dynamic JSON-shaped data over the protobuf wire
+// format.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Name() string { return "protobuf" }
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(s)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	s := &structpb.Struct{}
+	if err := proto.Unmarshal(data, s); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(s.AsMap())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// encodePacket marshals v with c. JSON is written untagged for wire
+// compatibility with plain-JSON peers; MessagePack and Protobuf are
+// prefixed with their wire tag so a subscriber can tell them apart from
+// JSON without any out-of-band signal.
+func encodePacket(c Codec, v interface{}) ([]byte, error) {
+	if c.Name() == "json" {
+		return c.Marshal(v)
+	}
+	tag, ok := codecTags[c.Name()]
+	if !ok {
+		return nil, fmt.Errorf("hemera: unknown codec %q", c.Name())
+	}
+	body, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tag}, body...), nil
+}
+
+// decodePacket picks the codec for data and unmarshals it into v,
+// regardless of which codec is configured locally. If the leading byte
+// matches a known MessagePack/Protobuf tag, that codec handles the
+// remainder; otherwise data is assumed to be plain JSON, which is what
+// lets a subscriber configured for any codec keep understanding requests
+// from JSON-only Hemera peers (this Go SDK's own older versions, the JS
+// SDK, etc).
+func decodePacket(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return fmt.Errorf("hemera: empty message")
+	}
+	if c, ok := codecsByTag[data[0]]; ok {
+		return c.Unmarshal(data[1:], v)
+	}
+	return JSONCodec{}.Unmarshal(data, v)
+}
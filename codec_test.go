@@ -0,0 +1,52 @@
+package hemera
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	codecs := []Codec{JSONCodec{}, MsgpackCodec{}, ProtobufCodec{}}
+
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			in := Packet{
+				Pattern: Pattern{"topic": "math", "cmd": "add"},
+				Result:  map[string]interface{}{"sum": float64(3)},
+			}
+
+			data, err := encodePacket(c, &in)
+			if err != nil {
+				t.Fatalf("encodePacket: %v", err)
+			}
+
+			var out Packet
+			if err := decodePacket(data, &out); err != nil {
+				t.Fatalf("decodePacket: %v", err)
+			}
+
+			if out.Pattern["topic"] != in.Pattern["topic"] || out.Pattern["cmd"] != in.Pattern["cmd"] {
+				t.Fatalf("Pattern mismatch: got %v, want %v", out.Pattern, in.Pattern)
+			}
+		})
+	}
+}
+
+// A plain-JSON peer that knows nothing about the wire tag scheme must
+// still be understood: decodePacket has to fall back to json.Unmarshal
+// when the leading byte isn't a known MessagePack/Protobuf tag.
+func TestDecodePacketPlainJSONCompat(t *testing.T) {
+	in := Packet{Pattern: Pattern{"topic": "math"}}
+	data, err := json.Marshal(&in)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	var out Packet
+	if err := decodePacket(data, &out); err != nil {
+		t.Fatalf("decodePacket on plain JSON: %v", err)
+	}
+	if out.Pattern["topic"] != "math" {
+		t.Fatalf("Pattern mismatch: got %v", out.Pattern)
+	}
+}
@@ -1,13 +1,14 @@
 package hemera
 
 import (
-	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"sync"
 	"time"
 
 	nats "github.com/nats-io/go-nats"
 	"github.com/nats-io/nuid"
+	opentracing "github.com/opentracing/opentracing-go"
 )
 
 const (
@@ -22,11 +23,21 @@ const (
 var (
 	ErrAddTopicRequired = errors.New("Topic is required")
 	ErrActTopicRequired = errors.New("Topic is required")
+	// ErrActTimeout is returned by Act when the NATS request timed out
+	// waiting for a reply.
+	ErrActTimeout = errors.New("hemera: act timed out waiting for a reply")
+	// ErrUnmarshalResponse is returned by Act when the reply payload could
+	// not be decoded with the configured Codec.
+	ErrUnmarshalResponse = errors.New("hemera: could not unmarshal response")
 )
 
 func GetDefaultOptions() Options {
 	opts := Options{
-		Timeout: RequestTimeout,
+		Timeout:      RequestTimeout,
+		Codec:        JSONCodec{},
+		Logger:       nopLogger{},
+		Metrics:      nopMetrics{},
+		RetryBackoff: DefaultRetryBackoff,
 	}
 	return opts
 }
@@ -36,6 +47,27 @@ type Option func(*Options) error
 
 type Options struct {
 	Timeout time.Duration
+	Codec   Codec
+	// Tracer, when set, receives a span for every Add handler invocation
+	// and every Act call so traces can be reported to Zipkin/Jaeger.
+	Tracer opentracing.Tracer
+	// ServiceName identifies this Hemera instance in emitted traces.
+	ServiceName string
+	// Logger receives structured reports for failures that previously
+	// terminated the process via log.Fatal.
+	Logger Logger
+	// Metrics receives call counts, latencies and in-flight gauges for
+	// every Act/Add invocation.
+	Metrics Metrics
+	// MaxRetries is how many times Act retries a request that fails with
+	// a transport-level error. Zero disables retries.
+	MaxRetries int
+	// RetryBackoff computes the delay before retry attempt number
+	// attempt (0-indexed). Defaults to DefaultRetryBackoff.
+	RetryBackoff func(attempt int) time.Duration
+	// CircuitBreaker, when set, guards Act with a per-topic circuit
+	// breaker. Nil disables it.
+	CircuitBreaker *CircuitBreakerConfig
 }
 
 // Reply is function type to represent the callback handler
@@ -43,13 +75,18 @@ type Reply func(interface{})
 type addHandler func(Pattern, Reply)
 type actHandler func(ClientResult)
 
-//Pattern the default struct to represent a pattern
+// Pattern the default struct to represent a pattern
 type Pattern map[string]interface{}
 
 // Hemera is the main struct
 type Hemera struct {
 	Conn *nats.Conn
 	Opts Options
+
+	middlewares map[Hook][]Middleware
+
+	breakersMu sync.Mutex
+	breakers   map[string]*breaker
 }
 
 type request struct {
@@ -76,7 +113,7 @@ type trace struct {
 	Duration     int64  `json:"duration"`
 }
 
-type packet struct {
+type Packet struct {
 	Pattern  Pattern                `json:"pattern"`
 	Meta     map[string]interface{} `json:"meta"`
 	Delegate map[string]interface{} `json:"delegate"`
@@ -105,76 +142,280 @@ func Timeout(t time.Duration) Option {
 	}
 }
 
+// WithCodec is an Option to set the wire codec used to marshal outgoing
+// packets. Incoming packets are always decoded with whichever codec their
+// wire tag identifies, so this only controls what this Hemera instance
+// writes.
+func WithCodec(c Codec) Option {
+	return func(o *Options) error {
+		o.Codec = c
+		return nil
+	}
+}
+
+// WithTracer is an Option to set the opentracing.Tracer used to report
+// spans for Add handler invocations and Act calls, e.g. a Zipkin or Jaeger
+// reporter.
+func WithTracer(t opentracing.Tracer) Option {
+	return func(o *Options) error {
+		o.Tracer = t
+		return nil
+	}
+}
+
+// ServiceName is an Option to set the service name attached to every trace
+// this Hemera instance produces.
+func ServiceName(name string) Option {
+	return func(o *Options) error {
+		o.ServiceName = name
+		return nil
+	}
+}
+
 // Add is a method to subscribe on a specific topic
 func (h *Hemera) Add(p Pattern, handler addHandler) (bool, error) {
 	topic, ok := p["topic"].(string)
 
 	if !ok {
-		log.Fatal("Topic is required in Add definition")
+		h.Opts.Logger.Error("topic is required in Add definition")
 		return false, ErrAddTopicRequired
 	}
 
-	h.Conn.QueueSubscribe(topic, topic, func(m *nats.Msg) {
-		pack := packet{}
-		json.Unmarshal(m.Data, &pack)
+	_, err := h.Conn.QueueSubscribe(topic, topic, func(m *nats.Msg) {
+		pack := Packet{}
+		if err := decodePacket(m.Data, &pack); err != nil {
+			h.Opts.Logger.Error("could not unmarshal request", "topic", topic, "error", err)
+			return
+		}
+
+		if err := h.runMiddlewares(OnServerPreHandler, &pack); err != nil {
+			h.Opts.Logger.Warn("OnServerPreHandler middleware rejected request", "topic", topic, "error", err)
+
+			// Pub/sub calls have no reply subject to answer on; a
+			// request/reply caller is otherwise left blocking until
+			// Timeout, and would then blindly retry a rejection that
+			// retrying can never fix.
+			if pack.Request.RequestType != PubsubType && m.Reply != "" {
+				h.replyWithError(m.Reply, p, pack.Trace, "MiddlewareError", err)
+			}
+			return
+		}
+
+		// Start a child span for this handler invocation, propagating the
+		// incoming trace (or minting a new one if this is the first hop).
+		childTrace := trace{
+			TraceID:      pack.Trace.TraceID,
+			ParentSpanID: pack.Trace.SpanID,
+			SpanID:       newSpanID(),
+			Timestamp:    nowMillis(),
+			Service:      h.Opts.ServiceName,
+			Method:       topic,
+		}
+		if childTrace.TraceID == "" {
+			childTrace.TraceID = newTraceID()
+		}
+		span := startSpan(h.Opts.Tracer, topic, childTrace, pack.Trace)
+		start := time.Now()
 
 		handler(pack.Pattern, func(payload interface{}) {
-			response := packet{
+			duration := time.Since(start)
+			childTrace.Duration = duration.Milliseconds()
+			finishSpan(span)
+
+			he, isErr := payload.(Error)
+			status := statusOK
+			if isErr {
+				status = statusError
+			}
+			h.Opts.Metrics.AddObserve(topic, status, duration)
+
+			// Pub/sub handlers have no reply subject to answer on; a
+			// request/reply handler always does.
+			if pack.Request.RequestType == PubsubType {
+				return
+			}
+
+			response := Packet{
 				Pattern: p,
 				Request: request{
 					ID:          nuid.Next(),
 					RequestType: RequestType,
 				},
+				Trace: childTrace,
 			}
 
 			// Check if error or message was passed
-			he, ok := payload.(Error)
-			if ok {
+			if isErr {
 				response.Error = &he
 			} else {
 				response.Result = payload
 			}
-			// Encode to JSON
-			data, _ := json.Marshal(&response)
+
+			if err := h.runMiddlewares(OnServerPreResponse, &response); err != nil {
+				h.Opts.Logger.Warn("OnServerPreResponse middleware failed", "topic", topic, "error", err)
+				response.Error = &Error{Name: "MiddlewareError", Message: err.Error()}
+			}
+
+			// Encode with the configured codec
+			data, err := encodePacket(h.Opts.Codec, &response)
+			if err != nil {
+				h.Opts.Logger.Error("could not marshal response", "topic", topic, "error", err)
+				// response.Result was presumably what failed to encode; a
+				// bare error packet still tells the caller its request
+				// failed instead of leaving it to time out and retry.
+				h.replyWithError(m.Reply, p, childTrace, "EncodeError", err)
+				return
+			}
 			// Send
-			h.Conn.Publish(m.Reply, data)
+			if err := h.Conn.Publish(m.Reply, data); err != nil {
+				h.Opts.Logger.Error("could not publish response", "topic", topic, "error", err)
+			}
 		})
 	})
 
+	if err != nil {
+		h.Opts.Logger.Error("could not subscribe", "topic", topic, "error", err)
+		return false, err
+	}
+
 	return true, nil
 }
 
+// replyWithError publishes a Packet carrying err as its Error to reply,
+// so a request/reply caller that was rejected before (or failed after) its
+// handler ran gets a real response instead of waiting out Timeout and
+// retrying a failure that retrying cannot fix.
+func (h *Hemera) replyWithError(reply string, p Pattern, t trace, name string, err error) {
+	response := Packet{
+		Pattern: p,
+		Request: request{
+			ID:          nuid.Next(),
+			RequestType: RequestType,
+		},
+		Trace: t,
+		Error: &Error{Name: name, Message: err.Error()},
+	}
+
+	data, encErr := encodePacket(h.Opts.Codec, &response)
+	if encErr != nil {
+		h.Opts.Logger.Error("could not marshal error response", "reply", reply, "error", encErr)
+		return
+	}
+	if pubErr := h.Conn.Publish(reply, data); pubErr != nil {
+		h.Opts.Logger.Error("could not publish error response", "reply", reply, "error", pubErr)
+	}
+}
+
 // Act is a method to send a message to a NATS subscriber which the specific topic
 func (h *Hemera) Act(p Pattern, handler actHandler) (bool, error) {
 
 	topic, ok := p["topic"].(string)
 
 	if !ok {
-		log.Fatal("Topic is required in Act call")
+		h.Opts.Logger.Error("topic is required in Act call")
 		return false, ErrActTopicRequired
 	}
 
-	request := packet{
+	actTrace := trace{
+		TraceID:   newTraceID(),
+		SpanID:    newSpanID(),
+		Timestamp: nowMillis(),
+		Service:   h.Opts.ServiceName,
+		Method:    topic,
+	}
+	span := startSpan(h.Opts.Tracer, topic, actTrace, trace{})
+	start := time.Now()
+
+	request := Packet{
 		Pattern: p,
 		Request: request{
 			ID:          nuid.Next(),
 			RequestType: RequestType,
 		},
+		Trace: actTrace,
 	}
 
-	data, _ := json.Marshal(&request)
-	m, err := h.Conn.Request(topic, data, h.Opts.Timeout*time.Millisecond)
+	if err := h.runMiddlewares(OnClientPreRequest, &request); err != nil {
+		finishSpan(span)
+		h.Opts.Logger.Warn("OnClientPreRequest middleware rejected request", "topic", topic, "error", err)
+		return false, err
+	}
 
+	data, err := encodePacket(h.Opts.Codec, &request)
 	if err != nil {
-		log.Fatal("Act could not be executed")
+		finishSpan(span)
+		h.Opts.Logger.Error("could not marshal request", "topic", topic, "error", err)
 		return false, err
 	}
 
-	pack := packet{}
-	mErr := json.Unmarshal(m.Data, &pack)
+	var brk *breaker
+	if h.Opts.CircuitBreaker != nil {
+		brk = h.breakerFor(topic)
+		if !brk.allow() {
+			finishSpan(span)
+			h.Opts.Logger.Warn("circuit breaker open, rejecting act", "topic", topic)
+			return false, ErrCircuitOpen
+		}
+		// A half-open breaker only lets its single trial through, and
+		// allow() grants that trial in the same call that leaves open, so
+		// being let through while half-open means this call made the move.
+		brk.mu.Lock()
+		halfOpened := brk.state == breakerHalfOpen
+		brk.mu.Unlock()
+		if halfOpened {
+			h.Opts.Logger.Warn("circuit breaker transitioned", "topic", topic, "state", breakerHalfOpen.String())
+			h.Opts.Metrics.BreakerStateChange(topic, breakerHalfOpen.String())
+		}
+	}
+
+	var m *nats.Msg
+	h.Opts.Metrics.ActInflightInc(topic)
+	for attempt := 0; ; attempt++ {
+		m, err = h.Conn.Request(topic, data, h.Opts.Timeout*time.Millisecond)
+		if err == nil || attempt >= h.Opts.MaxRetries || !isRetryableActErr(err) {
+			break
+		}
+		backoff := h.Opts.RetryBackoff(attempt)
+		h.Opts.Logger.Warn("retrying act", "topic", topic, "attempt", attempt, "error", err, "backoff", backoff)
+		time.Sleep(backoff)
+	}
+	h.Opts.Metrics.ActInflightDec(topic)
+
+	duration := time.Since(start)
+	actTrace.Duration = duration.Milliseconds()
+	finishSpan(span)
+
+	if brk != nil {
+		if state, transitioned := brk.recordResult(err == nil); transitioned {
+			h.Opts.Logger.Warn("circuit breaker transitioned", "topic", topic, "state", state.String())
+			h.Opts.Metrics.BreakerStateChange(topic, state.String())
+		}
+	}
+
+	if err != nil {
+		h.Opts.Metrics.ActObserve(topic, statusError, duration)
+		if err == nats.ErrTimeout {
+			err = fmt.Errorf("%w: %v", ErrActTimeout, err)
+		}
+		h.Opts.Logger.Error("act could not be executed", "topic", topic, "error", err)
+		return false, err
+	}
 
-	if mErr != nil {
-		log.Fatal("Act response could not be unmarshalled")
+	pack := Packet{}
+	if err := decodePacket(m.Data, &pack); err != nil {
+		h.Opts.Metrics.ActObserve(topic, statusError, duration)
+		err = fmt.Errorf("%w: %v", ErrUnmarshalResponse, err)
+		h.Opts.Logger.Error("act response could not be unmarshalled", "topic", topic, "error", err)
+		return false, err
+	}
+
+	status := statusOK
+	if pack.Error != nil {
+		status = statusError
+	}
+	h.Opts.Metrics.ActObserve(topic, status, duration)
+
+	if err := h.runMiddlewares(OnClientPostResponse, &pack); err != nil {
 		return false, err
 	}
 
@@ -186,3 +427,40 @@ func (h *Hemera) Act(p Pattern, handler actHandler) (bool, error) {
 
 	return true, nil
 }
+
+// Publish sends p as a fire-and-forget pub/sub message: no reply
+// subscription is opened and no timeout applies, unlike Act.
+func (h *Hemera) Publish(p Pattern) error {
+	topic, ok := p["topic"].(string)
+
+	if !ok {
+		h.Opts.Logger.Error("topic is required in Publish call")
+		return ErrActTopicRequired
+	}
+
+	request := Packet{
+		Pattern: p,
+		Request: request{
+			ID:          nuid.Next(),
+			RequestType: PubsubType,
+		},
+	}
+
+	if err := h.runMiddlewares(OnClientPreRequest, &request); err != nil {
+		h.Opts.Logger.Warn("OnClientPreRequest middleware rejected request", "topic", topic, "error", err)
+		return err
+	}
+
+	data, err := encodePacket(h.Opts.Codec, &request)
+	if err != nil {
+		h.Opts.Logger.Error("could not marshal request", "topic", topic, "error", err)
+		return err
+	}
+
+	if err := h.Conn.Publish(topic, data); err != nil {
+		h.Opts.Logger.Error("could not publish", "topic", topic, "error", err)
+		return err
+	}
+
+	return nil
+}
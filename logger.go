@@ -0,0 +1,30 @@
+package hemera
+
+// Logger is the structured logging contract Hemera uses to surface
+// failures that would otherwise be silently dropped or, worse, a
+// log.Fatal. Key-value pairs are passed as alternating key, value
+// arguments, the same convention zap's SugaredLogger and logrus use, so
+// adapting either (or slog) is a thin wrapper.
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// nopLogger is the default Logger: it discards everything.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// WithLogger is an Option to set the Logger used to report failures on
+// the Add and Act paths.
+func WithLogger(l Logger) Option {
+	return func(o *Options) error {
+		o.Logger = l
+		return nil
+	}
+}
@@ -0,0 +1,47 @@
+package hemera
+
+import "time"
+
+// Metrics is the instrumentation hook Act and Add call into for call
+// counts, latencies and in-flight requests. It is defined here, rather
+// than depending on a specific metrics backend, so the core module stays
+// free of that dependency; see the metrics/prometheus subpackage for a
+// Prometheus-backed implementation.
+type Metrics interface {
+	// ActInflightInc/ActInflightDec track requests currently awaiting a
+	// reply from Act.
+	ActInflightInc(topic string)
+	ActInflightDec(topic string)
+	// ActObserve records the outcome and duration of a finished Act call.
+	ActObserve(topic, status string, duration time.Duration)
+	// AddObserve records the outcome and duration of a finished Add
+	// handler invocation.
+	AddObserve(topic, status string, duration time.Duration)
+	// BreakerStateChange reports that topic's circuit breaker transitioned
+	// to state ("closed", "open" or "half-open").
+	BreakerStateChange(topic, state string)
+}
+
+// nopMetrics is the default Metrics: it discards everything.
+type nopMetrics struct{}
+
+func (nopMetrics) ActInflightInc(string)                    {}
+func (nopMetrics) ActInflightDec(string)                    {}
+func (nopMetrics) ActObserve(string, string, time.Duration) {}
+func (nopMetrics) AddObserve(string, string, time.Duration) {}
+func (nopMetrics) BreakerStateChange(string, string)        {}
+
+// WithMetrics is an Option to set the Metrics sink used to instrument
+// Act/Add calls. Use the metrics/prometheus subpackage's MetricsRegisterer
+// to report these to Prometheus.
+func WithMetrics(m Metrics) Option {
+	return func(o *Options) error {
+		o.Metrics = m
+		return nil
+	}
+}
+
+const (
+	statusOK    = "ok"
+	statusError = "error"
+)
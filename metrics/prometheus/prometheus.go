@@ -0,0 +1,92 @@
+// Package prometheus adapts Hemera's internal Metrics interface to
+// github.com/prometheus/client_golang, so the core module does not need to
+// depend on it.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Datazource/go-hemera"
+)
+
+// Metrics reports hemera_act_total, hemera_add_total,
+// hemera_act_duration_seconds, hemera_add_handler_duration_seconds,
+// hemera_act_inflight and hemera_circuit_breaker_state to Prometheus.
+type Metrics struct {
+	actTotal     *prometheus.CounterVec
+	addTotal     *prometheus.CounterVec
+	actDuration  *prometheus.HistogramVec
+	addDuration  *prometheus.HistogramVec
+	actInflight  *prometheus.GaugeVec
+	breakerState *prometheus.GaugeVec
+}
+
+// New builds a Metrics and registers its collectors with reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		actTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hemera_act_total",
+			Help: "Total number of Act calls.",
+		}, []string{"topic", "status"}),
+		addTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hemera_add_total",
+			Help: "Total number of Add handler invocations.",
+		}, []string{"topic", "status"}),
+		actDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "hemera_act_duration_seconds",
+			Help: "Duration of Act calls in seconds.",
+		}, []string{"topic"}),
+		addDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "hemera_add_handler_duration_seconds",
+			Help: "Duration of Add handler invocations in seconds.",
+		}, []string{"topic"}),
+		actInflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hemera_act_inflight",
+			Help: "Number of Act calls currently awaiting a reply.",
+		}, []string{"topic"}),
+		breakerState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hemera_circuit_breaker_state",
+			Help: "Circuit breaker state per topic: 0=closed, 1=half-open, 2=open.",
+		}, []string{"topic"}),
+	}
+	reg.MustRegister(m.actTotal, m.addTotal, m.actDuration, m.addDuration, m.actInflight, m.breakerState)
+	return m
+}
+
+func (m *Metrics) ActInflightInc(topic string) {
+	m.actInflight.WithLabelValues(topic).Inc()
+}
+
+func (m *Metrics) ActInflightDec(topic string) {
+	m.actInflight.WithLabelValues(topic).Dec()
+}
+
+func (m *Metrics) ActObserve(topic, status string, duration time.Duration) {
+	m.actTotal.WithLabelValues(topic, status).Inc()
+	m.actDuration.WithLabelValues(topic).Observe(duration.Seconds())
+}
+
+func (m *Metrics) AddObserve(topic, status string, duration time.Duration) {
+	m.addTotal.WithLabelValues(topic, status).Inc()
+	m.addDuration.WithLabelValues(topic).Observe(duration.Seconds())
+}
+
+func (m *Metrics) BreakerStateChange(topic, state string) {
+	value := 0.0
+	switch state {
+	case "half-open":
+		value = 1
+	case "open":
+		value = 2
+	}
+	m.breakerState.WithLabelValues(topic).Set(value)
+}
+
+// MetricsRegisterer is a hemera.Option that registers Prometheus
+// collectors with reg and wires them up as the Hemera instance's Metrics
+// sink.
+func MetricsRegisterer(reg prometheus.Registerer) hemera.Option {
+	return hemera.WithMetrics(New(reg))
+}
@@ -0,0 +1,52 @@
+package hemera
+
+// Handler processes a Packet as it moves through Add or Act. Returning a
+// non-nil error short-circuits the remaining chain.
+type Handler func(*Packet) error
+
+// Middleware wraps a Handler to add cross-cutting behaviour (auth,
+// caching, validation, metrics, circuit breaking, ...) without forking Add
+// or Act. It mirrors the endpoint middleware pattern used by go-kit.
+type Middleware func(next Handler) Handler
+
+// Hook identifies which point in the Add/Act lifecycle a Middleware runs
+// at.
+type Hook int
+
+const (
+	// OnClientPreRequest runs on the outgoing Packet before Act sends it.
+	OnClientPreRequest Hook = iota
+	// OnClientPostResponse runs on the incoming Packet after Act decodes
+	// it, before the result is handed to the caller's handler.
+	OnClientPostResponse
+	// OnServerPreHandler runs on the incoming Packet after Add decodes it,
+	// before the subscribed handler is invoked.
+	OnServerPreHandler
+	// OnServerPreResponse runs on the outgoing Packet after the subscribed
+	// handler replies, before Add publishes it.
+	OnServerPreResponse
+)
+
+// Use registers middleware to run at hook. Middleware registered for the
+// same Hook run in the order they were added.
+func (h *Hemera) Use(hook Hook, mw ...Middleware) {
+	if h.middlewares == nil {
+		h.middlewares = make(map[Hook][]Middleware)
+	}
+	h.middlewares[hook] = append(h.middlewares[hook], mw...)
+}
+
+// runMiddlewares runs every Middleware registered for hook against pkt, in
+// registration order, stopping at the first error.
+func (h *Hemera) runMiddlewares(hook Hook, pkt *Packet) error {
+	mws := h.middlewares[hook]
+	if len(mws) == 0 {
+		return nil
+	}
+
+	next := Handler(func(*Packet) error { return nil })
+	for i := len(mws) - 1; i >= 0; i-- {
+		next = mws[i](next)
+	}
+	return next(pkt)
+}
@@ -0,0 +1,108 @@
+package hemera
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	natstest "github.com/nats-io/gnatsd/test"
+	nats "github.com/nats-io/go-nats"
+)
+
+func TestRunMiddlewaresOrder(t *testing.T) {
+	h := &Hemera{}
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(pkt *Packet) error {
+				calls = append(calls, name)
+				return next(pkt)
+			}
+		}
+	}
+	h.Use(OnServerPreHandler, record("first"), record("second"))
+	h.Use(OnServerPreHandler, record("third"))
+
+	if err := h.runMiddlewares(OnServerPreHandler, &Packet{}); err != nil {
+		t.Fatalf("runMiddlewares: %v", err)
+	}
+
+	want := []string{"first", "second", "third"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i, name := range want {
+		if calls[i] != name {
+			t.Fatalf("calls = %v, want %v", calls, want)
+		}
+	}
+}
+
+func TestRunMiddlewaresShortCircuits(t *testing.T) {
+	h := &Hemera{}
+
+	errRejected := errors.New("rejected")
+	var ranSecond bool
+	h.Use(OnClientPreRequest, func(next Handler) Handler {
+		return func(pkt *Packet) error { return errRejected }
+	})
+	h.Use(OnClientPreRequest, func(next Handler) Handler {
+		return func(pkt *Packet) error {
+			ranSecond = true
+			return next(pkt)
+		}
+	})
+
+	err := h.runMiddlewares(OnClientPreRequest, &Packet{})
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("runMiddlewares err = %v, want %v", err, errRejected)
+	}
+	if ranSecond {
+		t.Fatalf("middleware after the rejecting one must not run")
+	}
+}
+
+// TestAddRejectsWithErrorReply guards against a regression where a
+// request/reply call rejected by an OnServerPreHandler middleware got no
+// reply at all, leaving the Act caller to wait out the full Timeout and
+// receive ErrActTimeout instead of the real rejection reason.
+func TestAddRejectsWithErrorReply(t *testing.T) {
+	srv := natstest.RunDefaultServer()
+	defer srv.Shutdown()
+
+	conn, err := nats.Connect(nats.DefaultURL)
+	if err != nil {
+		t.Fatalf("nats.Connect: %v", err)
+	}
+	defer conn.Close()
+
+	h, err := NewHemera(conn)
+	if err != nil {
+		t.Fatalf("NewHemera: %v", err)
+	}
+
+	errRejected := errors.New("not authorized")
+	h.Use(OnServerPreHandler, func(next Handler) Handler {
+		return func(pkt *Packet) error { return errRejected }
+	})
+
+	if _, err := h.Add(Pattern{"topic": "math"}, func(p Pattern, reply Reply) {
+		t.Fatalf("handler must not run once OnServerPreHandler rejects the request")
+	}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	msg, err := conn.Request("math", []byte(`{"pattern":{"topic":"math"},"request":{"type":"request"}}`), time.Second)
+	if err != nil {
+		t.Fatalf("expected an immediate error reply, got: %v", err)
+	}
+
+	var reply Packet
+	if err := decodePacket(msg.Data, &reply); err != nil {
+		t.Fatalf("decodePacket: %v", err)
+	}
+	if reply.Error == nil || reply.Error.Message != errRejected.Error() {
+		t.Fatalf("reply.Error = %+v, want message %q", reply.Error, errRejected.Error())
+	}
+}
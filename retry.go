@@ -0,0 +1,200 @@
+package hemera
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/go-nats"
+)
+
+// defaultBackoffBase/defaultBackoffCap parameterize DefaultRetryBackoff.
+const (
+	defaultBackoffBase = 50 * time.Millisecond
+	defaultBackoffCap  = 2 * time.Second
+)
+
+// DefaultRetryBackoff is the default RetryBackoff: exponential with
+// jitter, i.e. min(cap, base * 2^attempt) + rand[0, base).
+func DefaultRetryBackoff(attempt int) time.Duration {
+	backoff := defaultBackoffCap
+	if shifted := defaultBackoffBase << uint(attempt); shifted > 0 && shifted < defaultBackoffCap {
+		backoff = shifted
+	}
+	return backoff + time.Duration(rand.Int63n(int64(defaultBackoffBase)))
+}
+
+// MaxRetries is an Option to set how many times Act retries a request that
+// fails with a transport-level error (a NATS timeout or connection error).
+// Application-level errors (packet.Error) are never retried.
+func MaxRetries(n int) Option {
+	return func(o *Options) error {
+		o.MaxRetries = n
+		return nil
+	}
+}
+
+// WithRetryBackoff is an Option to override the delay Act waits between
+// retries. The default is DefaultRetryBackoff.
+func WithRetryBackoff(f func(attempt int) time.Duration) Option {
+	return func(o *Options) error {
+		o.RetryBackoff = f
+		return nil
+	}
+}
+
+// isRetryableActErr reports whether err is a transport-level failure worth
+// retrying, as opposed to an application-level packet.Error.
+func isRetryableActErr(err error) bool {
+	switch {
+	case errors.Is(err, nats.ErrTimeout),
+		errors.Is(err, nats.ErrNoServers),
+		errors.Is(err, nats.ErrConnectionClosed),
+		errors.Is(err, nats.ErrConnectionReconnecting),
+		errors.Is(err, nats.ErrStaleConnection):
+		return true
+	default:
+		return false
+	}
+}
+
+// CircuitBreakerConfig configures the per-topic circuit breaker guarding
+// Act.
+type CircuitBreakerConfig struct {
+	// FailureRatio is the fraction of failed calls, out of all calls
+	// recorded since the breaker last closed (or was created), that trips
+	// the breaker open.
+	FailureRatio float64
+	// MinRequests is the minimum number of calls observed in the current
+	// window before FailureRatio is evaluated.
+	MinRequests int
+	// Cooldown is how long the breaker stays open before letting a single
+	// half-open trial request through.
+	Cooldown time.Duration
+}
+
+// DefaultCircuitBreakerConfig returns the defaults used when
+// WithCircuitBreaker is given a zero-value CircuitBreakerConfig.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureRatio: 0.5,
+		MinRequests:  10,
+		Cooldown:     5 * time.Second,
+	}
+}
+
+// WithCircuitBreaker is an Option that enables a per-topic circuit breaker
+// in front of Act. A zero-value cfg is replaced with
+// DefaultCircuitBreakerConfig.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return func(o *Options) error {
+		if cfg == (CircuitBreakerConfig{}) {
+			cfg = DefaultCircuitBreakerConfig()
+		}
+		o.CircuitBreaker = &cfg
+		return nil
+	}
+}
+
+// ErrCircuitOpen is returned by Act when the topic's circuit breaker is
+// open and rejecting requests.
+var ErrCircuitOpen = errors.New("hemera: circuit breaker open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker is a closed/open/half-open circuit breaker for a single topic.
+type breaker struct {
+	cfg CircuitBreakerConfig
+
+	mu            sync.Mutex
+	state         breakerState
+	requests      int
+	failures      int
+	openedAt      time.Time
+	halfOpenTrial bool
+}
+
+// allow reports whether a call should be let through, flipping an open
+// breaker to half-open once its cooldown has elapsed. While half-open,
+// only a single trial request is allowed through at a time; concurrent
+// callers are rejected until recordResult resolves that trial.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen {
+		if time.Since(b.openedAt) < b.cfg.Cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+	}
+	if b.state == breakerHalfOpen {
+		if b.halfOpenTrial {
+			return false
+		}
+		b.halfOpenTrial = true
+	}
+	return true
+}
+
+// recordResult folds in the outcome of a call, returning the breaker's
+// state and whether this call caused a transition.
+func (b *breaker) recordResult(ok bool) (newState breakerState, transitioned bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.halfOpenTrial = false
+		if ok {
+			b.state, b.requests, b.failures = breakerClosed, 0, 0
+		} else {
+			b.state, b.openedAt = breakerOpen, time.Now()
+		}
+		return b.state, true
+	}
+
+	b.requests++
+	if !ok {
+		b.failures++
+	}
+	if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+		b.state, b.openedAt, b.requests, b.failures = breakerOpen, time.Now(), 0, 0
+		return b.state, true
+	}
+	return b.state, false
+}
+
+// breakerFor returns the circuit breaker for topic, creating it on first
+// use.
+func (h *Hemera) breakerFor(topic string) *breaker {
+	h.breakersMu.Lock()
+	defer h.breakersMu.Unlock()
+
+	if h.breakers == nil {
+		h.breakers = make(map[string]*breaker)
+	}
+	b, ok := h.breakers[topic]
+	if !ok {
+		b = &breaker{cfg: *h.Opts.CircuitBreaker}
+		h.breakers[topic] = b
+	}
+	return b
+}
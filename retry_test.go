@@ -0,0 +1,129 @@
+package hemera
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	nats "github.com/nats-io/go-nats"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	cfg := CircuitBreakerConfig{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Cooldown:     10 * time.Millisecond,
+	}
+	b := &breaker{cfg: cfg}
+
+	if !b.allow() {
+		t.Fatalf("closed breaker must allow requests")
+	}
+
+	// 3 failures out of 3 requests is over the ratio but should not
+	// trip the breaker until MinRequests is reached.
+	for i := 0; i < 3; i++ {
+		if state, transitioned := b.recordResult(false); transitioned {
+			t.Fatalf("unexpected transition to %s before MinRequests is reached", state)
+		}
+	}
+
+	state, transitioned := b.recordResult(false)
+	if !transitioned || state != breakerOpen {
+		t.Fatalf("expected transition to open on 4th failure, got state=%v transitioned=%v", state, transitioned)
+	}
+	if b.allow() {
+		t.Fatalf("open breaker must reject requests before cooldown elapses")
+	}
+
+	time.Sleep(cfg.Cooldown * 2)
+	if !b.allow() {
+		t.Fatalf("breaker should allow a trial request once cooldown has elapsed")
+	}
+	if b.state != breakerHalfOpen {
+		t.Fatalf("expected half-open after cooldown, got %v", b.state)
+	}
+
+	state, transitioned = b.recordResult(false)
+	if !transitioned || state != breakerOpen {
+		t.Fatalf("a failed half-open trial must reopen the breaker, got state=%v transitioned=%v", state, transitioned)
+	}
+
+	time.Sleep(cfg.Cooldown * 2)
+	if !b.allow() {
+		t.Fatalf("breaker should allow a second trial once cooldown has elapsed again")
+	}
+	state, transitioned = b.recordResult(true)
+	if !transitioned || state != breakerClosed {
+		t.Fatalf("a successful half-open trial must close the breaker, got state=%v transitioned=%v", state, transitioned)
+	}
+}
+
+// TestBreakerHalfOpenSingleTrial guards against a regression where every
+// concurrent caller fell through allow()'s open-breaker check once the
+// state flipped to half-open, letting an unbounded number of trial
+// requests through instead of exactly one.
+func TestBreakerHalfOpenSingleTrial(t *testing.T) {
+	cfg := CircuitBreakerConfig{
+		FailureRatio: 0.5,
+		MinRequests:  1,
+		Cooldown:     10 * time.Millisecond,
+	}
+	b := &breaker{cfg: cfg}
+
+	if state, transitioned := b.recordResult(false); !transitioned || state != breakerOpen {
+		t.Fatalf("expected transition to open, got state=%v transitioned=%v", state, transitioned)
+	}
+	time.Sleep(cfg.Cooldown * 2)
+
+	const callers = 20
+	allowed := 0
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			defer wg.Done()
+			if b.allow() {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 1 {
+		t.Fatalf("expected exactly 1 of %d concurrent callers through a half-open breaker, got %d", callers, allowed)
+	}
+}
+
+func TestDefaultRetryBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		d := DefaultRetryBackoff(attempt)
+		if d <= 0 {
+			t.Fatalf("attempt %d: backoff must be positive, got %v", attempt, d)
+		}
+		if d > defaultBackoffCap+defaultBackoffBase {
+			t.Fatalf("attempt %d: backoff %v exceeds cap+jitter bound", attempt, d)
+		}
+	}
+}
+
+func TestIsRetryableActErr(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nats.ErrTimeout, true},
+		{nats.ErrNoServers, true},
+		{nats.ErrConnectionClosed, true},
+		{ErrActTopicRequired, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := isRetryableActErr(c.err); got != c.want {
+			t.Errorf("isRetryableActErr(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package hemera
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// newTraceID returns a 128-bit trace identifier encoded as 32 hex
+// characters, matching the B3 propagation format used across Hemera SDKs.
+func newTraceID() string {
+	return newHexID(16)
+}
+
+// newSpanID returns a 64-bit span identifier encoded as 16 hex characters,
+// matching the B3 propagation format used across Hemera SDKs.
+func newSpanID() string {
+	return newHexID(8)
+}
+
+func newHexID(n int) string {
+	b := make([]byte, n)
+	// crypto/rand.Read never returns a short read without an error, and a
+	// failure here only degrades trace correlation, so it is not worth
+	// failing the request over.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// B3 header keys used to round-trip the IDs carried in packet.Trace
+// through the configured Tracer's own Extract, so ChildOf establishes a
+// real SpanContext relationship between the Add and Act spans rather than
+// just tagging otherwise-unrelated root spans with matching IDs.
+const (
+	b3TraceIDHeader  = "x-b3-traceid"
+	b3SpanIDHeader   = "x-b3-spanid"
+	b3ParentIDHeader = "x-b3-parentspanid"
+	b3SampledHeader  = "x-b3-sampled"
+)
+
+// extractSpanContext rebuilds the SpanContext described by parent via
+// tracer's own Extract. It fails (deliberately, via
+// ErrSpanContextNotFound) when parent has no TraceID, which is the case
+// for the first hop of a trace.
+func extractSpanContext(tracer opentracing.Tracer, parent trace) (opentracing.SpanContext, error) {
+	if parent.TraceID == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	carrier := opentracing.TextMapCarrier{
+		b3TraceIDHeader: parent.TraceID,
+		b3SpanIDHeader:  parent.SpanID,
+		b3SampledHeader: "1",
+	}
+	if parent.ParentSpanID != "" {
+		carrier[b3ParentIDHeader] = parent.ParentSpanID
+	}
+	return tracer.Extract(opentracing.TextMap, carrier)
+}
+
+// startSpan starts a span for operationName if a Tracer is configured. If
+// parent carries a TraceID, it is extracted back into a SpanContext and
+// passed as opentracing.ChildOf so the new span is properly linked to it;
+// otherwise the span starts as a new root. The span is also tagged with
+// the B3 identifiers in t for backends that prefer reading them directly.
+func startSpan(tracer opentracing.Tracer, operationName string, t trace, parent trace) opentracing.Span {
+	if tracer == nil {
+		return nil
+	}
+
+	var opts []opentracing.StartSpanOption
+	if sc, err := extractSpanContext(tracer, parent); err == nil {
+		opts = append(opts, opentracing.ChildOf(sc))
+	}
+
+	span := tracer.StartSpan(operationName, opts...)
+	span.SetTag("hemera.trace_id", t.TraceID)
+	span.SetTag("hemera.span_id", t.SpanID)
+	if t.ParentSpanID != "" {
+		span.SetTag("hemera.parent_span_id", t.ParentSpanID)
+	}
+
+	return span
+}
+
+func finishSpan(span opentracing.Span) {
+	if span != nil {
+		span.Finish()
+	}
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,142 @@
+package hemera
+
+import (
+	"strconv"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// b3HexPropagator is a mocktracer.Extractor that understands the b3 header
+// keys extractSpanContext actually writes, unlike mocktracer's own TextMap
+// propagator (which looks for its own "mockpfx-ids-*" keys). It lets these
+// tests assert on real ChildOf linkage instead of mocktracer silently
+// handing back an empty SpanContext for unrecognized keys.
+type b3HexPropagator struct{}
+
+func (b3HexPropagator) Extract(carrier interface{}) (mocktracer.MockSpanContext, error) {
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return mocktracer.MockSpanContext{}, opentracing.ErrInvalidCarrier
+	}
+
+	var traceID, spanID string
+	_ = reader.ForeachKey(func(k, v string) error {
+		switch k {
+		case b3TraceIDHeader:
+			traceID = v
+		case b3SpanIDHeader:
+			spanID = v
+		}
+		return nil
+	})
+	if traceID == "" {
+		return mocktracer.MockSpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+
+	tid, err := strconv.ParseInt(traceID, 16, 64)
+	if err != nil {
+		return mocktracer.MockSpanContext{}, err
+	}
+	sid, err := strconv.ParseInt(spanID, 16, 64)
+	if err != nil {
+		return mocktracer.MockSpanContext{}, err
+	}
+	return mocktracer.MockSpanContext{TraceID: int(tid), SpanID: int(sid), Sampled: true}, nil
+}
+
+func (b3HexPropagator) Inject(mocktracer.MockSpanContext, interface{}) error { return nil }
+
+func newB3MockTracer() *mocktracer.MockTracer {
+	tracer := mocktracer.New()
+	tracer.RegisterExtractor(opentracing.TextMap, b3HexPropagator{})
+	return tracer
+}
+
+// TestExtractSpanContextNoParent pins that a parent with no TraceID (the
+// first hop of a trace) is reported as not found, rather than an
+// extraction error or a bogus zero-value context.
+func TestExtractSpanContextNoParent(t *testing.T) {
+	tracer := newB3MockTracer()
+
+	_, err := extractSpanContext(tracer, trace{})
+	if err != opentracing.ErrSpanContextNotFound {
+		t.Fatalf("extractSpanContext() err = %v, want ErrSpanContextNotFound", err)
+	}
+}
+
+// TestExtractSpanContextWithParent pins that a parent carrying a TraceID
+// round-trips through the tracer's own Extract into a usable SpanContext.
+func TestExtractSpanContextWithParent(t *testing.T) {
+	tracer := newB3MockTracer()
+	parent := trace{TraceID: "a1", SpanID: "7"}
+
+	sc, err := extractSpanContext(tracer, parent)
+	if err != nil {
+		t.Fatalf("extractSpanContext: %v", err)
+	}
+	mockSC, ok := sc.(mocktracer.MockSpanContext)
+	if !ok {
+		t.Fatalf("extractSpanContext returned %T, want mocktracer.MockSpanContext", sc)
+	}
+	if mockSC.TraceID != 0xa1 || mockSC.SpanID != 0x7 {
+		t.Fatalf("extractSpanContext() = %+v, want TraceID=0xa1 SpanID=0x7", mockSC)
+	}
+}
+
+// TestStartSpanLinksToParent guards against a regression where startSpan
+// stopped passing opentracing.ChildOf(extracted parent) and every span
+// came back as an unrelated root, breaking trace correlation across an
+// Add/Act hop.
+func TestStartSpanLinksToParent(t *testing.T) {
+	tracer := newB3MockTracer()
+	parent := trace{TraceID: "a1", SpanID: "7"}
+	child := trace{TraceID: "a1", ParentSpanID: "7", SpanID: "2a"}
+
+	span := startSpan(tracer, "math", child, parent)
+	finishSpan(span)
+
+	finished := tracer.FinishedSpans()
+	if len(finished) != 1 {
+		t.Fatalf("got %d finished spans, want 1", len(finished))
+	}
+	got := finished[0]
+	if got.SpanContext.TraceID != 0xa1 {
+		t.Fatalf("child span TraceID = %#x, want 0xa1 (same trace as parent)", got.SpanContext.TraceID)
+	}
+	if got.ParentID != 0x7 {
+		t.Fatalf("child span ParentID = %#x, want 0x7 (parent's SpanID)", got.ParentID)
+	}
+	if tag := got.Tag("hemera.trace_id"); tag != "a1" {
+		t.Fatalf("hemera.trace_id tag = %v, want %q", tag, "a1")
+	}
+}
+
+// TestStartSpanRoot guards against a regression where a first-hop span
+// (no parent TraceID) was spuriously linked to a parent anyway.
+func TestStartSpanRoot(t *testing.T) {
+	tracer := newB3MockTracer()
+	root := trace{TraceID: "b2", SpanID: "3c"}
+
+	span := startSpan(tracer, "math", root, trace{})
+	finishSpan(span)
+
+	finished := tracer.FinishedSpans()
+	if len(finished) != 1 {
+		t.Fatalf("got %d finished spans, want 1", len(finished))
+	}
+	if got := finished[0].ParentID; got != 0 {
+		t.Fatalf("root span ParentID = %#x, want 0", got)
+	}
+}
+
+// TestStartSpanNilTracer pins that startSpan/finishSpan are no-ops when no
+// Tracer is configured, rather than panicking.
+func TestStartSpanNilTracer(t *testing.T) {
+	span := startSpan(nil, "math", trace{}, trace{})
+	if span != nil {
+		t.Fatalf("startSpan with a nil tracer = %v, want nil", span)
+	}
+	finishSpan(span)
+}